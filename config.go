@@ -0,0 +1,449 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// envPrefix is prepended to every option name to form its environment
+// variable, e.g. the "interval" option is read from IPW_INTERVAL.
+const envPrefix = "IPW_"
+
+// rawSettings holds every layered option as a loosely-typed string (or
+// string slice, for repeatable options) so that default, config-file,
+// and environment values can be merged before being handed to the flag
+// package as defaults. The command-line flags then apply on top, so any
+// flag the user actually typed wins over all three earlier layers.
+type rawSettings struct {
+	Interval         string
+	LogFile          string
+	Endpoint         []string
+	Quorum           string
+	QuietMode        string
+	MaxRetries       string
+	RetryBaseDelay   string
+	RetryMaxDelay    string
+	RetryJitter      string
+	BreakerThreshold string
+	Notify           []string
+	HTTPAddr         string
+	LogFormat        string
+	LogMaxSizeMB     string
+	LogMaxAgeDays    string
+	LogMaxBackups    string
+}
+
+// defaultRawSettings returns the application's built-in defaults, matching
+// the values the individual flags used before config-file/env support was
+// added.
+func defaultRawSettings() rawSettings {
+	return rawSettings{
+		Interval:         "60",
+		LogFile:          "",
+		Quorum:           "1",
+		QuietMode:        "false",
+		MaxRetries:       "5",
+		RetryBaseDelay:   "500ms",
+		RetryMaxDelay:    "30s",
+		RetryJitter:      "true",
+		BreakerThreshold: "5",
+		HTTPAddr:         "",
+		LogFormat:        "logfmt",
+		LogMaxSizeMB:     "0",
+		LogMaxAgeDays:    "0",
+		LogMaxBackups:    "0",
+	}
+}
+
+// configFileKeys are the recognised option names in a config file, used to
+// warn about typos/unknown keys instead of silently ignoring them.
+var configFileKeys = map[string]bool{
+	"interval":          true,
+	"log":               true,
+	"endpoint":          true,
+	"quorum":            true,
+	"quiet":             true,
+	"max_retries":       true,
+	"retry_base_delay":  true,
+	"retry_max_delay":   true,
+	"retry_jitter":      true,
+	"breaker_threshold": true,
+	"notify":            true,
+	"http_addr":         true,
+	"log_format":        true,
+	"log_max_size_mb":   true,
+	"log_max_age_days":  true,
+	"log_max_backups":   true,
+}
+
+// applyRawValue copies a single decoded config file or environment value
+// into the matching rawSettings field, by its canonical (underscore-style)
+// key name.
+func (r *rawSettings) applyRawValue(key string, value interface{}) {
+	switch key {
+	case "interval":
+		r.Interval = toScalarString(value)
+	case "log":
+		r.LogFile = toScalarString(value)
+	case "endpoint":
+		r.Endpoint = toStringSlice(value)
+	case "quorum":
+		r.Quorum = toScalarString(value)
+	case "quiet":
+		r.QuietMode = toScalarString(value)
+	case "max_retries":
+		r.MaxRetries = toScalarString(value)
+	case "retry_base_delay":
+		r.RetryBaseDelay = toScalarString(value)
+	case "retry_max_delay":
+		r.RetryMaxDelay = toScalarString(value)
+	case "retry_jitter":
+		r.RetryJitter = toScalarString(value)
+	case "breaker_threshold":
+		r.BreakerThreshold = toScalarString(value)
+	case "notify":
+		r.Notify = toStringSlice(value)
+	case "http_addr":
+		r.HTTPAddr = toScalarString(value)
+	case "log_format":
+		r.LogFormat = toScalarString(value)
+	case "log_max_size_mb":
+		r.LogMaxSizeMB = toScalarString(value)
+	case "log_max_age_days":
+		r.LogMaxAgeDays = toScalarString(value)
+	case "log_max_backups":
+		r.LogMaxBackups = toScalarString(value)
+	}
+}
+
+// toScalarString renders a decoded JSON/YAML scalar (string, float64, bool)
+// as plain text suitable for strconv/time.ParseDuration.
+func toScalarString(v interface{}) string {
+	switch t := v.(type) {
+	case string:
+		return t
+	case float64:
+		return strconv.FormatFloat(t, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(t)
+	default:
+		return fmt.Sprintf("%v", t)
+	}
+}
+
+// toStringSlice renders a decoded JSON/YAML list (or single scalar) as a
+// string slice.
+func toStringSlice(v interface{}) []string {
+	switch t := v.(type) {
+	case []string:
+		return t
+	case []interface{}:
+		out := make([]string, 0, len(t))
+		for _, item := range t {
+			out = append(out, toScalarString(item))
+		}
+		return out
+	default:
+		return []string{toScalarString(v)}
+	}
+}
+
+// loadConfigFile reads path (YAML or JSON, chosen by extension, falling
+// back to JSON-then-YAML detection) and merges recognised keys into raw.
+// Unknown keys produce a warning rather than a hard failure.
+func loadConfigFile(raw *rawSettings, path string) error {
+	if path == "" {
+		return nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read config file %s: %v", path, err)
+	}
+
+	values, err := decodeConfigFile(path, data)
+	if err != nil {
+		return fmt.Errorf("failed to parse config file %s: %v", path, err)
+	}
+
+	for key, value := range values {
+		canonical := strings.ToLower(key)
+		if !configFileKeys[canonical] {
+			logWarn(fmt.Sprintf("Unknown config file key %q ignored", key), kv("event", "config_unknown_key"), kv("key", key))
+			continue
+		}
+		raw.applyRawValue(canonical, value)
+	}
+
+	return nil
+}
+
+// decodeConfigFile parses the file content as JSON or YAML depending on
+// its extension, defaulting to JSON for unrecognised extensions.
+func decodeConfigFile(path string, data []byte) (map[string]interface{}, error) {
+	if strings.HasSuffix(path, ".yaml") || strings.HasSuffix(path, ".yml") {
+		return parseSimpleYAML(data)
+	}
+
+	var values map[string]interface{}
+	if err := json.Unmarshal(data, &values); err == nil {
+		return values, nil
+	}
+	return parseSimpleYAML(data)
+}
+
+// parseSimpleYAML parses the flat subset of YAML this program's config
+// needs: scalar "key: value" lines and block lists of the form
+//
+//	key:
+//	  - item1
+//	  - item2
+//
+// It deliberately does not implement the full YAML spec (nesting, anchors,
+// flow collections, multi-line strings); that's far more than a handful of
+// flat settings warrants, and pulling in a YAML library isn't possible
+// without a dependency manifest in this tree.
+func parseSimpleYAML(data []byte) (map[string]interface{}, error) {
+	values := make(map[string]interface{})
+	var currentListKey string
+
+	for _, line := range strings.Split(string(data), "\n") {
+		if idx := strings.Index(line, "#"); idx >= 0 {
+			line = line[:idx]
+		}
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" {
+			continue
+		}
+
+		if strings.HasPrefix(trimmed, "- ") {
+			if currentListKey == "" {
+				return nil, fmt.Errorf("list item %q has no preceding key", trimmed)
+			}
+			item := unquote(strings.TrimSpace(strings.TrimPrefix(trimmed, "- ")))
+			list, _ := values[currentListKey].([]string)
+			values[currentListKey] = append(list, item)
+			continue
+		}
+
+		parts := strings.SplitN(trimmed, ":", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("malformed line %q", trimmed)
+		}
+		key := strings.TrimSpace(parts[0])
+		value := strings.TrimSpace(parts[1])
+
+		if value == "" {
+			currentListKey = key
+			values[key] = []string{}
+			continue
+		}
+
+		currentListKey = ""
+		values[key] = unquote(value)
+	}
+
+	return values, nil
+}
+
+// unquote strips a single layer of matching single or double quotes.
+func unquote(s string) string {
+	if len(s) >= 2 {
+		if (s[0] == '"' && s[len(s)-1] == '"') || (s[0] == '\'' && s[len(s)-1] == '\'') {
+			return s[1 : len(s)-1]
+		}
+	}
+	return s
+}
+
+// loadEnvSettings overlays environment variables (prefixed IPW_) onto raw.
+func loadEnvSettings(raw *rawSettings) {
+	if v, ok := os.LookupEnv(envPrefix + "INTERVAL"); ok {
+		raw.Interval = v
+	}
+	if v, ok := os.LookupEnv(envPrefix + "LOG"); ok {
+		raw.LogFile = v
+	}
+	if v, ok := os.LookupEnv(envPrefix + "ENDPOINT"); ok {
+		raw.Endpoint = splitAndTrim(v)
+	}
+	if v, ok := os.LookupEnv(envPrefix + "QUORUM"); ok {
+		raw.Quorum = v
+	}
+	if v, ok := os.LookupEnv(envPrefix + "QUIET"); ok {
+		raw.QuietMode = v
+	}
+	if v, ok := os.LookupEnv(envPrefix + "MAX_RETRIES"); ok {
+		raw.MaxRetries = v
+	}
+	if v, ok := os.LookupEnv(envPrefix + "RETRY_BASE_DELAY"); ok {
+		raw.RetryBaseDelay = v
+	}
+	if v, ok := os.LookupEnv(envPrefix + "RETRY_MAX_DELAY"); ok {
+		raw.RetryMaxDelay = v
+	}
+	if v, ok := os.LookupEnv(envPrefix + "RETRY_JITTER"); ok {
+		raw.RetryJitter = v
+	}
+	if v, ok := os.LookupEnv(envPrefix + "BREAKER_THRESHOLD"); ok {
+		raw.BreakerThreshold = v
+	}
+	if v, ok := os.LookupEnv(envPrefix + "NOTIFY"); ok {
+		raw.Notify = splitAndTrim(v)
+	}
+	if v, ok := os.LookupEnv(envPrefix + "HTTP_ADDR"); ok {
+		raw.HTTPAddr = v
+	}
+	if v, ok := os.LookupEnv(envPrefix + "LOG_FORMAT"); ok {
+		raw.LogFormat = v
+	}
+	if v, ok := os.LookupEnv(envPrefix + "LOG_MAX_SIZE_MB"); ok {
+		raw.LogMaxSizeMB = v
+	}
+	if v, ok := os.LookupEnv(envPrefix + "LOG_MAX_AGE_DAYS"); ok {
+		raw.LogMaxAgeDays = v
+	}
+	if v, ok := os.LookupEnv(envPrefix + "LOG_MAX_BACKUPS"); ok {
+		raw.LogMaxBackups = v
+	}
+}
+
+func splitAndTrim(v string) []string {
+	var out []string
+	for _, part := range strings.Split(v, ",") {
+		part = strings.TrimSpace(part)
+		if part != "" {
+			out = append(out, part)
+		}
+	}
+	return out
+}
+
+// findFlagValue scans raw CLI args for "-name value", "-name=value", or
+// their "--" equivalents, returning the value and whether it was found.
+// It's used to resolve --config ahead of the main flag set, since the
+// config file must be loaded before flag defaults are computed.
+func findFlagValue(args []string, name string) (string, bool) {
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		for _, prefix := range []string{"-" + name, "--" + name} {
+			if arg == prefix {
+				if i+1 < len(args) {
+					return args[i+1], true
+				}
+				return "", true
+			}
+			if strings.HasPrefix(arg, prefix+"=") {
+				return strings.TrimPrefix(arg, prefix+"="), true
+			}
+		}
+	}
+	return "", false
+}
+
+// parseDurationSetting parses a raw duration string, falling back to
+// fallback (and logging a warning) if it's empty or invalid.
+func parseDurationSetting(raw string, fallback time.Duration) time.Duration {
+	if raw == "" {
+		return fallback
+	}
+	d, err := time.ParseDuration(raw)
+	if err != nil {
+		logMessage("Warning: invalid duration %q, using %s", raw, fallback)
+		return fallback
+	}
+	return d
+}
+
+func parseIntSetting(raw string, fallback int) int {
+	if raw == "" {
+		return fallback
+	}
+	n, err := strconv.Atoi(raw)
+	if err != nil {
+		logMessage("Warning: invalid integer %q, using %d", raw, fallback)
+		return fallback
+	}
+	return n
+}
+
+// parseLogFormatSetting validates a raw log format string, falling back to
+// fallback (and logging a warning) unless it's "logfmt" or "json".
+func parseLogFormatSetting(raw string, fallback string) string {
+	if raw == "" {
+		return fallback
+	}
+	if raw != "logfmt" && raw != "json" {
+		logMessage("Warning: invalid log format %q, using %s", raw, fallback)
+		return fallback
+	}
+	return raw
+}
+
+func parseBoolSetting(raw string, fallback bool) bool {
+	if raw == "" {
+		return fallback
+	}
+	b, err := strconv.ParseBool(raw)
+	if err != nil {
+		logMessage("Warning: invalid boolean %q, using %v", raw, fallback)
+		return fallback
+	}
+	return b
+}
+
+// dumpableConfig is the JSON shape printed by --dump-config: the effective
+// configuration after merging defaults, config file, environment, and
+// command-line flags.
+type dumpableConfig struct {
+	Interval         int      `json:"interval"`
+	LogFile          string   `json:"log"`
+	Endpoint         []string `json:"endpoint"`
+	Quorum           int      `json:"quorum"`
+	QuietMode        bool     `json:"quiet"`
+	MaxRetries       int      `json:"max_retries"`
+	RetryBaseDelay   string   `json:"retry_base_delay"`
+	RetryMaxDelay    string   `json:"retry_max_delay"`
+	RetryJitter      bool     `json:"retry_jitter"`
+	BreakerThreshold int      `json:"breaker_threshold"`
+	Notify           []string `json:"notify"`
+	HTTPAddr         string   `json:"http_addr"`
+	LogFormat        string   `json:"log_format"`
+	LogMaxSizeMB     int      `json:"log_max_size_mb"`
+	LogMaxAgeDays    int      `json:"log_max_age_days"`
+	LogMaxBackups    int      `json:"log_max_backups"`
+}
+
+// printDumpedConfig writes the effective, fully-resolved configuration to
+// stdout as JSON.
+func printDumpedConfig(config *Config, endpointURLs, notifyURIs []string) error {
+	dump := dumpableConfig{
+		Interval:         config.Interval,
+		LogFile:          config.LogFile,
+		Endpoint:         endpointURLs,
+		Quorum:           config.Quorum,
+		QuietMode:        config.QuietMode,
+		MaxRetries:       config.MaxRetries,
+		RetryBaseDelay:   config.RetryBaseDelay.String(),
+		RetryMaxDelay:    config.RetryMaxDelay.String(),
+		RetryJitter:      config.RetryJitter,
+		BreakerThreshold: config.BreakerThreshold,
+		Notify:           notifyURIs,
+		HTTPAddr:         config.HTTPAddr,
+		LogFormat:        config.LogFormat,
+		LogMaxSizeMB:     config.LogMaxSizeMB,
+		LogMaxAgeDays:    config.LogMaxAgeDays,
+		LogMaxBackups:    config.LogMaxBackups,
+	}
+
+	encoded, err := json.MarshalIndent(dump, "", "  ")
+	if err != nil {
+		return err
+	}
+	fmt.Println(string(encoded))
+	return nil
+}
@@ -0,0 +1,279 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestParseSimpleYAML(t *testing.T) {
+	data := []byte(`
+# a comment
+interval: 30
+log: /var/log/ip-watcher.log
+quorum: 2
+endpoint:
+  - https://a.example.com
+  - https://b.example.com
+`)
+
+	values, err := parseSimpleYAML(data)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if values["interval"] != "30" {
+		t.Errorf("Expected interval 30, got %v", values["interval"])
+	}
+	if values["log"] != "/var/log/ip-watcher.log" {
+		t.Errorf("Expected log path, got %v", values["log"])
+	}
+	endpoints, ok := values["endpoint"].([]string)
+	if !ok || len(endpoints) != 2 {
+		t.Fatalf("Expected 2 endpoints, got %v", values["endpoint"])
+	}
+	if endpoints[0] != "https://a.example.com" {
+		t.Errorf("Expected first endpoint, got %s", endpoints[0])
+	}
+}
+
+func TestLoadConfigFileJSON(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.json")
+	content := `{"interval": 45, "quorum": 2, "retry_jitter": false, "endpoint": ["https://a.example.com"]}`
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	raw := defaultRawSettings()
+	if err := loadConfigFile(&raw, path); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if raw.Interval != "45" {
+		t.Errorf("Expected interval 45, got %s", raw.Interval)
+	}
+	if raw.Quorum != "2" {
+		t.Errorf("Expected quorum 2, got %s", raw.Quorum)
+	}
+	if raw.RetryJitter != "false" {
+		t.Errorf("Expected retry_jitter false, got %s", raw.RetryJitter)
+	}
+	if len(raw.Endpoint) != 1 || raw.Endpoint[0] != "https://a.example.com" {
+		t.Errorf("Expected 1 endpoint, got %v", raw.Endpoint)
+	}
+}
+
+func TestLoadConfigFileYAML(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.yaml")
+	content := "interval: 90\nquiet: true\nnotify:\n  - webhook+https://hooks.example.com/ip\n"
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	raw := defaultRawSettings()
+	if err := loadConfigFile(&raw, path); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if raw.Interval != "90" {
+		t.Errorf("Expected interval 90, got %s", raw.Interval)
+	}
+	if raw.QuietMode != "true" {
+		t.Errorf("Expected quiet true, got %s", raw.QuietMode)
+	}
+	if len(raw.Notify) != 1 || raw.Notify[0] != "webhook+https://hooks.example.com/ip" {
+		t.Errorf("Expected 1 notify URI, got %v", raw.Notify)
+	}
+}
+
+func TestLoadConfigFileWarnsOnUnknownKey(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.json")
+	content := `{"interval": 30, "bogus_key": "x"}`
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	raw := defaultRawSettings()
+	if err := loadConfigFile(&raw, path); err != nil {
+		t.Fatalf("Expected unknown keys to warn, not fail, got %v", err)
+	}
+	if raw.Interval != "30" {
+		t.Errorf("Expected known keys to still be applied, got interval=%s", raw.Interval)
+	}
+}
+
+func TestLoadEnvSettingsOverridesDefaults(t *testing.T) {
+	os.Setenv("IPW_INTERVAL", "120")
+	os.Setenv("IPW_ENDPOINT", "https://a.example.com, https://b.example.com")
+	defer os.Unsetenv("IPW_INTERVAL")
+	defer os.Unsetenv("IPW_ENDPOINT")
+
+	raw := defaultRawSettings()
+	loadEnvSettings(&raw)
+
+	if raw.Interval != "120" {
+		t.Errorf("Expected env to override interval, got %s", raw.Interval)
+	}
+	if len(raw.Endpoint) != 2 {
+		t.Errorf("Expected 2 endpoints from env, got %v", raw.Endpoint)
+	}
+}
+
+func TestPrecedenceFileThenEnvThenDefault(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.json")
+	content := `{"interval": 45, "quorum": 3}`
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	os.Setenv("IPW_INTERVAL", "77")
+	defer os.Unsetenv("IPW_INTERVAL")
+
+	raw := defaultRawSettings()
+	if err := loadConfigFile(&raw, path); err != nil {
+		t.Fatal(err)
+	}
+	loadEnvSettings(&raw)
+
+	// Env overrides the file's interval...
+	if raw.Interval != "77" {
+		t.Errorf("Expected env to win over file for interval, got %s", raw.Interval)
+	}
+	// ...but the file's quorum (not set in env) survives.
+	if raw.Quorum != "3" {
+		t.Errorf("Expected file value to survive when env doesn't set it, got %s", raw.Quorum)
+	}
+	// ...and defaults survive when neither file nor env set a field.
+	if raw.MaxRetries != "5" {
+		t.Errorf("Expected default max_retries to survive, got %s", raw.MaxRetries)
+	}
+}
+
+func TestPrecedenceFlagBeatsEnvBeatsFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.json")
+	if err := os.WriteFile(path, []byte(`{"interval": 45}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	os.Setenv("IPW_INTERVAL", "77")
+	defer os.Unsetenv("IPW_INTERVAL")
+
+	raw := defaultRawSettings()
+	if err := loadConfigFile(&raw, path); err != nil {
+		t.Fatal(err)
+	}
+	loadEnvSettings(&raw)
+
+	// With no flag typed, the resolved default is the env value, which
+	// itself already beat the file's value.
+	var interval int
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.IntVar(&interval, "interval", parseIntSetting(raw.Interval, 60), "interval")
+	if err := fs.Parse([]string{}); err != nil {
+		t.Fatal(err)
+	}
+	if interval != 77 {
+		t.Errorf("Expected env to win over file when no flag is typed, got %d", interval)
+	}
+
+	// An explicit flag overrides both the file and the environment.
+	fs2 := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs2.IntVar(&interval, "interval", parseIntSetting(raw.Interval, 60), "interval")
+	if err := fs2.Parse([]string{"-interval=99"}); err != nil {
+		t.Fatal(err)
+	}
+	if interval != 99 {
+		t.Errorf("Expected an explicit flag to win over file and env, got %d", interval)
+	}
+}
+
+func TestPrintDumpedConfigOutputsEffectiveConfig(t *testing.T) {
+	config := &Config{
+		Interval:         60,
+		LogFile:          "/var/log/ip-watcher.log",
+		Quorum:           2,
+		QuietMode:        true,
+		MaxRetries:       5,
+		RetryBaseDelay:   500 * time.Millisecond,
+		RetryMaxDelay:    30 * time.Second,
+		RetryJitter:      true,
+		BreakerThreshold: 5,
+		HTTPAddr:         ":9090",
+		LogFormat:        "json",
+		LogMaxSizeMB:     100,
+		LogMaxAgeDays:    7,
+		LogMaxBackups:    3,
+	}
+
+	oldStdout := os.Stdout
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	os.Stdout = w
+
+	dumpErr := printDumpedConfig(config, []string{"https://a.example.com"}, []string{"webhook+https://hooks.example.com/ip"})
+
+	w.Close()
+	os.Stdout = oldStdout
+	if dumpErr != nil {
+		t.Fatalf("Expected no error, got %v", dumpErr)
+	}
+
+	output, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var dump dumpableConfig
+	if err := json.Unmarshal(output, &dump); err != nil {
+		t.Fatalf("Expected valid JSON output, got error %v for %q", err, output)
+	}
+
+	if dump.Interval != 60 {
+		t.Errorf("Expected interval 60, got %d", dump.Interval)
+	}
+	if dump.Quorum != 2 {
+		t.Errorf("Expected quorum 2, got %d", dump.Quorum)
+	}
+	if len(dump.Endpoint) != 1 || dump.Endpoint[0] != "https://a.example.com" {
+		t.Errorf("Expected 1 endpoint, got %v", dump.Endpoint)
+	}
+	if len(dump.Notify) != 1 || dump.Notify[0] != "webhook+https://hooks.example.com/ip" {
+		t.Errorf("Expected 1 notify URI, got %v", dump.Notify)
+	}
+	if dump.LogFormat != "json" {
+		t.Errorf("Expected log_format json, got %s", dump.LogFormat)
+	}
+	if dump.LogMaxSizeMB != 100 || dump.LogMaxAgeDays != 7 || dump.LogMaxBackups != 3 {
+		t.Errorf("Expected log rotation settings to round-trip, got %+v", dump)
+	}
+}
+
+func TestParseDurationSettingFallsBackOnInvalid(t *testing.T) {
+	if got := parseDurationSetting("not-a-duration", time.Second); got != time.Second {
+		t.Errorf("Expected fallback duration, got %v", got)
+	}
+	if got := parseDurationSetting("2s", time.Second); got != 2*time.Second {
+		t.Errorf("Expected parsed duration, got %v", got)
+	}
+}
+
+func TestFindFlagValue(t *testing.T) {
+	args := []string{"-interval=30", "--config", "/etc/ip-watcher.yaml", "-quiet"}
+
+	if v, ok := findFlagValue(args, "config"); !ok || v != "/etc/ip-watcher.yaml" {
+		t.Errorf("Expected to find config path, got %q (ok=%v)", v, ok)
+	}
+	if _, ok := findFlagValue(args, "missing"); ok {
+		t.Error("Expected missing flag to not be found")
+	}
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+// startMetricsServer starts the optional embedded HTTP server exposing
+// /healthz, /ip, and /metrics on config.HTTPAddr. It runs in a background
+// goroutine and logs (rather than exits on) a failure to bind.
+func startMetricsServer(config *Config) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", healthzHandler(config))
+	mux.HandleFunc("/ip", ipHandler(config))
+	mux.HandleFunc("/metrics", metricsHandler())
+
+	server := &http.Server{
+		Addr:              config.HTTPAddr,
+		Handler:           mux,
+		ReadHeaderTimeout: 5 * time.Second,
+		ReadTimeout:       5 * time.Second,
+		WriteTimeout:      10 * time.Second,
+	}
+	go func() {
+		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			logError(fmt.Sprintf("HTTP server on %s failed: %v", config.HTTPAddr, err),
+				kv("event", "http_server_failed"), kv("addr", config.HTTPAddr), kv("err", err.Error()))
+		}
+	}()
+
+	logInfo(fmt.Sprintf("Serving /healthz, /ip and /metrics on %s", config.HTTPAddr),
+		kv("event", "http_server_started"), kv("addr", config.HTTPAddr))
+}
+
+// healthzHandler reports 200 if the last check succeeded within
+// 2*Interval seconds, 503 otherwise (including if no check has succeeded
+// yet).
+func healthzHandler(config *Config) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		lastSuccess := atomic.LoadInt64(&metrics.lastSuccessTimestamp)
+		maxAge := time.Duration(2*config.Interval) * time.Second
+
+		if lastSuccess == 0 || time.Since(time.Unix(lastSuccess, 0)) > maxAge {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			fmt.Fprintln(w, "unhealthy")
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		fmt.Fprintln(w, "ok")
+	}
+}
+
+// ipStatus is the JSON shape served by /ip.
+type ipStatus struct {
+	IPv4        string     `json:"ipv4"`
+	IPv6        string     `json:"ipv6"`
+	LastChecked *time.Time `json:"last_checked,omitempty"`
+	LastChange  *time.Time `json:"last_change,omitempty"`
+}
+
+// ipHandler serves the last-known addresses and check/change timestamps.
+func ipHandler(config *Config) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		config.LastKnownIPLock.Lock()
+		status := ipStatus{IPv4: config.LastKnownIPv4, IPv6: config.LastKnownIPv6}
+		config.LastKnownIPLock.Unlock()
+
+		if ts := atomic.LoadInt64(&metrics.lastCheckTimestamp); ts != 0 {
+			t := time.Unix(ts, 0)
+			status.LastChecked = &t
+		}
+		if ts := atomic.LoadInt64(&metrics.lastChangeTimestamp); ts != 0 {
+			t := time.Unix(ts, 0)
+			status.LastChange = &t
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(status)
+	}
+}
+
+// metricsHandler serves the package-level metrics registry in Prometheus
+// text exposition format.
+func metricsHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		metrics.writeTo(w)
+	}
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHealthzHandlerReflectsCheckSuccess(t *testing.T) {
+	resetMetricsForTest()
+
+	v4 := ipServer("1.2.3.4")
+	defer v4.Close()
+	config := quorumTestConfig([]string{v4.URL}, 1)
+	config.Interval = 60
+	checkIP(config)
+
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/healthz", nil)
+	healthzHandler(config)(rec, req)
+
+	if rec.Code != 200 {
+		t.Errorf("Expected 200 after a successful check, got %d", rec.Code)
+	}
+
+	metrics.lastSuccessTimestamp = time.Now().Add(-time.Hour).Unix()
+
+	rec = httptest.NewRecorder()
+	healthzHandler(config)(rec, req)
+	if rec.Code != 503 {
+		t.Errorf("Expected 503 once the last success is older than 2*Interval, got %d", rec.Code)
+	}
+}
+
+func TestIPHandlerReturnsLastKnownAddresses(t *testing.T) {
+	resetMetricsForTest()
+
+	v4 := ipServer("1.2.3.4")
+	defer v4.Close()
+	config := quorumTestConfig([]string{v4.URL}, 1)
+	checkIP(config)
+
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/ip", nil)
+	ipHandler(config)(rec, req)
+
+	var status ipStatus
+	if err := json.NewDecoder(rec.Body).Decode(&status); err != nil {
+		t.Fatalf("Failed to decode /ip response: %v", err)
+	}
+	if status.IPv4 != "1.2.3.4" {
+		t.Errorf("Expected ipv4 1.2.3.4, got %q", status.IPv4)
+	}
+	if status.LastChecked == nil {
+		t.Error("Expected last_checked to be set after a check")
+	}
+}
+
+func TestMetricsHandlerScrapesCounters(t *testing.T) {
+	resetMetricsForTest()
+
+	v4 := ipServer("1.2.3.4")
+	defer v4.Close()
+	config := quorumTestConfig([]string{v4.URL, "http://nonexistent.invalid.example"}, 2)
+	checkIP(config) // no quorum: 1 of 2 providers agree
+	checkIP(config) // still no quorum
+
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/metrics", nil)
+	metricsHandler()(rec, req)
+	body := rec.Body.String()
+
+	if !strings.Contains(body, "ipwatcher_checks_total 2") {
+		t.Errorf("Expected 2 checks scraped, got:\n%s", body)
+	}
+	if !strings.Contains(body, `ipwatcher_check_failures_total{reason="quorum"} 2`) {
+		t.Errorf("Expected 2 quorum failures scraped, got:\n%s", body)
+	}
+}
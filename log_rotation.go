@@ -0,0 +1,252 @@
+package main
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// rotatingWriter is an io.Writer over a log file that rotates itself once
+// it crosses maxSizeBytes, keeping at most maxBackups old generations
+// (the newest as "<path>.1", older ones gzip-compressed as "<path>.N.gz")
+// and pruning backups older than maxAge. A zero maxSizeBytes, maxAge, or
+// maxBackups disables that particular limit. It is safe for concurrent
+// use by the check loop and notifier goroutines.
+type rotatingWriter struct {
+	mu sync.Mutex
+
+	path         string
+	maxSizeBytes int64
+	maxAge       time.Duration
+	maxBackups   int
+
+	file *os.File
+	size int64
+}
+
+// newRotatingWriter opens (creating if necessary) the log file at path and
+// returns a writer that rotates it according to the given limits.
+func newRotatingWriter(path string, maxSizeMB, maxAgeDays, maxBackups int) (*rotatingWriter, error) {
+	w := &rotatingWriter{
+		path:         path,
+		maxSizeBytes: int64(maxSizeMB) * 1024 * 1024,
+		maxAge:       time.Duration(maxAgeDays) * 24 * time.Hour,
+		maxBackups:   maxBackups,
+	}
+	if err := w.open(); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+func (w *rotatingWriter) open() error {
+	if err := os.MkdirAll(filepath.Dir(w.path), 0755); err != nil {
+		return fmt.Errorf("failed to create log directory: %v", err)
+	}
+
+	file, err := os.OpenFile(w.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open log file: %v", err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("failed to stat log file: %v", err)
+	}
+
+	w.file = file
+	w.size = info.Size()
+	return nil
+}
+
+// Write implements io.Writer, rotating first if p would push the file past
+// maxSizeBytes.
+func (w *rotatingWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.maxSizeBytes > 0 && w.size+int64(len(p)) > w.maxSizeBytes {
+		if err := w.rotate(); err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := w.file.Write(p)
+	w.size += int64(n)
+	return n, err
+}
+
+// rotate closes the current file, shifts existing backups up by one
+// generation (compressing everything but the newest), reopens a fresh file
+// at path, and prunes backups beyond maxBackups or older than maxAge. It
+// always attempts to reopen the file at path, even if an earlier step
+// failed, so a single rotation error doesn't leave w.file pointed at a
+// closed handle and every subsequent Write silently failing forever.
+func (w *rotatingWriter) rotate() error {
+	var rotateErr error
+	if err := w.file.Close(); err != nil {
+		rotateErr = fmt.Errorf("failed to close log file for rotation: %v", err)
+	}
+
+	if rotateErr == nil {
+		rotateErr = w.shiftBackups()
+	}
+	if rotateErr == nil {
+		if err := os.Rename(w.path, w.path+".1"); err != nil && !os.IsNotExist(err) {
+			rotateErr = fmt.Errorf("failed to rotate log file: %v", err)
+		}
+	}
+	if rotateErr == nil {
+		w.pruneBackups()
+	}
+
+	if err := w.open(); err != nil {
+		if rotateErr != nil {
+			return rotateErr
+		}
+		return err
+	}
+	return rotateErr
+}
+
+// shiftBackups renames "<path>.N" / "<path>.N.gz" to "<path>.(N+1)[.gz]",
+// starting from the highest generation so nothing is overwritten, then
+// gzip-compresses the backup that is about to become "<path>.2" so only
+// the newest backup ("<path>.1") stays uncompressed.
+func (w *rotatingWriter) shiftBackups() error {
+	for n := w.backupCount(); n >= 1; n-- {
+		oldPlain := fmt.Sprintf("%s.%d", w.path, n)
+		oldGzip := oldPlain + ".gz"
+		newPlain := fmt.Sprintf("%s.%d", w.path, n+1)
+		newGzip := newPlain + ".gz"
+
+		if _, err := os.Stat(oldGzip); err == nil {
+			if err := os.Rename(oldGzip, newGzip); err != nil {
+				return fmt.Errorf("failed to shift log backup: %v", err)
+			}
+			continue
+		}
+		if _, err := os.Stat(oldPlain); err == nil {
+			if n == 1 {
+				if err := os.Rename(oldPlain, newPlain); err != nil {
+					return fmt.Errorf("failed to shift log backup: %v", err)
+				}
+				if err := gzipFile(newPlain); err != nil {
+					return err
+				}
+			} else if err := os.Rename(oldPlain, newPlain); err != nil {
+				return fmt.Errorf("failed to shift log backup: %v", err)
+			}
+		}
+	}
+	return nil
+}
+
+// backupCount returns the highest existing "<path>.N" or "<path>.N.gz"
+// generation number, or 0 if there are none.
+func (w *rotatingWriter) backupCount() int {
+	dir := filepath.Dir(w.path)
+	base := filepath.Base(w.path)
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return 0
+	}
+
+	highest := 0
+	for _, entry := range entries {
+		name := entry.Name()
+		if !strings.HasPrefix(name, base+".") {
+			continue
+		}
+		suffix := strings.TrimPrefix(name, base+".")
+		suffix = strings.TrimSuffix(suffix, ".gz")
+		n, err := strconv.Atoi(suffix)
+		if err != nil {
+			continue
+		}
+		if n > highest {
+			highest = n
+		}
+	}
+	return highest
+}
+
+// pruneBackups deletes backups beyond maxBackups (oldest first) and any
+// backup whose modification time is older than maxAge.
+func (w *rotatingWriter) pruneBackups() {
+	dir := filepath.Dir(w.path)
+	base := filepath.Base(w.path)
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return
+	}
+
+	type backup struct {
+		path string
+		gen  int
+		mod  time.Time
+	}
+	var backups []backup
+	for _, entry := range entries {
+		name := entry.Name()
+		if !strings.HasPrefix(name, base+".") {
+			continue
+		}
+		suffix := strings.TrimSuffix(strings.TrimPrefix(name, base+"."), ".gz")
+		gen, err := strconv.Atoi(suffix)
+		if err != nil {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		backups = append(backups, backup{path: filepath.Join(dir, name), gen: gen, mod: info.ModTime()})
+	}
+
+	sort.Slice(backups, func(i, j int) bool { return backups[i].gen < backups[j].gen })
+
+	for _, b := range backups {
+		tooOld := w.maxAge > 0 && time.Since(b.mod) > w.maxAge
+		tooMany := w.maxBackups > 0 && b.gen > w.maxBackups
+		if tooOld || tooMany {
+			os.Remove(b.path)
+		}
+	}
+}
+
+// gzipFile compresses path in place, replacing it with path+".gz".
+func gzipFile(path string) error {
+	in, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open log backup for compression: %v", err)
+	}
+	defer in.Close()
+
+	out, err := os.Create(path + ".gz")
+	if err != nil {
+		return fmt.Errorf("failed to create compressed log backup: %v", err)
+	}
+	defer out.Close()
+
+	gz := gzip.NewWriter(out)
+	if _, err := io.Copy(gz, in); err != nil {
+		gz.Close()
+		return fmt.Errorf("failed to compress log backup: %v", err)
+	}
+	if err := gz.Close(); err != nil {
+		return fmt.Errorf("failed to finalize compressed log backup: %v", err)
+	}
+
+	in.Close()
+	return os.Remove(path)
+}
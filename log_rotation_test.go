@@ -0,0 +1,124 @@
+package main
+
+import (
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+func TestRotatingWriterRotatesPastSizeLimit(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.log")
+
+	w, err := newRotatingWriter(path, 0, 0, 0)
+	if err != nil {
+		t.Fatalf("newRotatingWriter: %v", err)
+	}
+	w.maxSizeBytes = 64 // force rotation well before a real MB-sized file
+
+	line := []byte("this is a log line that is long enough to force rotation\n")
+	for i := 0; i < 10; i++ {
+		if _, err := w.Write(line); err != nil {
+			t.Fatalf("Write: %v", err)
+		}
+	}
+
+	if _, err := os.Stat(path + ".1"); err != nil {
+		t.Errorf("Expected rotated backup %s.1 to exist, got %v", path, err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("Expected active log file to still exist, got %v", err)
+	}
+	if info.Size() > w.maxSizeBytes {
+		t.Errorf("Expected active log file to be under the size limit after rotation, got %d bytes", info.Size())
+	}
+}
+
+func TestRotatingWriterCompressesOlderBackups(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.log")
+
+	w, err := newRotatingWriter(path, 0, 0, 0)
+	if err != nil {
+		t.Fatalf("newRotatingWriter: %v", err)
+	}
+	w.maxSizeBytes = 32
+
+	line := []byte("rotate me please rotate me\n")
+	for i := 0; i < 20; i++ {
+		if _, err := w.Write(line); err != nil {
+			t.Fatalf("Write: %v", err)
+		}
+	}
+
+	if _, err := os.Stat(path + ".2.gz"); err != nil {
+		t.Fatalf("Expected second-generation backup to be gzip-compressed as %s.2.gz, got %v", path, err)
+	}
+
+	gzFile, err := os.Open(path + ".2.gz")
+	if err != nil {
+		t.Fatalf("Open compressed backup: %v", err)
+	}
+	defer gzFile.Close()
+	gz, err := gzip.NewReader(gzFile)
+	if err != nil {
+		t.Fatalf("Expected valid gzip stream, got %v", err)
+	}
+	defer gz.Close()
+	if _, err := io.ReadAll(gz); err != nil {
+		t.Errorf("Expected compressed backup to decompress cleanly, got %v", err)
+	}
+}
+
+func TestRotatingWriterPrunesBeyondMaxBackups(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.log")
+
+	w, err := newRotatingWriter(path, 0, 0, 2)
+	if err != nil {
+		t.Fatalf("newRotatingWriter: %v", err)
+	}
+	w.maxSizeBytes = 32
+
+	line := []byte("rotate me please rotate me\n")
+	for i := 0; i < 40; i++ {
+		if _, err := w.Write(line); err != nil {
+			t.Fatalf("Write: %v", err)
+		}
+	}
+
+	if w.backupCount() > 2 {
+		t.Errorf("Expected at most 2 backups to survive pruning, found generation %d", w.backupCount())
+	}
+}
+
+func TestRotatingWriterSafeForConcurrentWrites(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.log")
+
+	w, err := newRotatingWriter(path, 0, 0, 0)
+	if err != nil {
+		t.Fatalf("newRotatingWriter: %v", err)
+	}
+	w.maxSizeBytes = 128
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 20; j++ {
+				w.Write([]byte("concurrent log line from check loop or notifier\n"))
+			}
+		}()
+	}
+	wg.Wait()
+
+	if _, err := os.Stat(path); err != nil {
+		t.Errorf("Expected active log file to survive concurrent writes, got %v", err)
+	}
+}
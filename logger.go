@@ -0,0 +1,136 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Level is a log severity, ordered from least to most severe.
+type Level int
+
+const (
+	LevelDebug Level = iota
+	LevelInfo
+	LevelWarn
+	LevelError
+)
+
+// String implements fmt.Stringer.
+func (l Level) String() string {
+	switch l {
+	case LevelDebug:
+		return "DEBUG"
+	case LevelInfo:
+		return "INFO"
+	case LevelWarn:
+		return "WARN"
+	case LevelError:
+		return "ERROR"
+	default:
+		return "INFO"
+	}
+}
+
+// field is a single structured key/value pair attached to a log line, e.g.
+// kv("event", "ip_change").
+type field struct {
+	Key   string
+	Value interface{}
+}
+
+func kv(key string, value interface{}) field {
+	return field{Key: key, Value: value}
+}
+
+// structuredLogger writes leveled, structured log lines in logfmt or JSON.
+// It is safe for concurrent use by the check loop and notifier goroutines.
+type structuredLogger struct {
+	mu     sync.Mutex
+	out    io.Writer
+	format string // "logfmt" or "json"
+}
+
+// appLogger is the process-wide logger, reconfigured by setupLogging once
+// flags are parsed. It defaults to logfmt on stderr, matching the standard
+// library log package's own default, so log calls made before setupLogging
+// runs (or in tests) still produce readable output.
+var appLogger = &structuredLogger{out: os.Stderr, format: "logfmt"}
+
+// configureLogger points the global logger at out, using format ("json"
+// selects JSON; anything else, including the default "logfmt", selects
+// logfmt).
+func configureLogger(out io.Writer, format string) {
+	appLogger.mu.Lock()
+	defer appLogger.mu.Unlock()
+	appLogger.out = out
+	if format == "json" {
+		appLogger.format = "json"
+	} else {
+		appLogger.format = "logfmt"
+	}
+}
+
+func (l *structuredLogger) log(level Level, msg string, fields []field) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.format == "json" {
+		l.writeJSON(level, msg, fields)
+	} else {
+		l.writeLogfmt(level, msg, fields)
+	}
+}
+
+func (l *structuredLogger) writeLogfmt(level Level, msg string, fields []field) {
+	var b strings.Builder
+	fmt.Fprintf(&b, "ts=%s level=%s msg=%s", time.Now().Format(time.RFC3339), level, logfmtValue(msg))
+	for _, f := range fields {
+		fmt.Fprintf(&b, " %s=%s", f.Key, logfmtValue(f.Value))
+	}
+	b.WriteByte('\n')
+	io.WriteString(l.out, b.String())
+}
+
+// logfmtValue renders v as a logfmt value, quoting it if it contains
+// whitespace or characters that would otherwise break key=value parsing.
+func logfmtValue(v interface{}) string {
+	s := fmt.Sprintf("%v", v)
+	if s == "" || strings.ContainsAny(s, " \t\r\n\"=") {
+		return strconv.Quote(s)
+	}
+	return s
+}
+
+func (l *structuredLogger) writeJSON(level Level, msg string, fields []field) {
+	entry := make(map[string]interface{}, len(fields)+3)
+	entry["ts"] = time.Now().Format(time.RFC3339)
+	entry["level"] = level.String()
+	entry["msg"] = msg
+	for _, f := range fields {
+		entry[f.Key] = f.Value
+	}
+
+	encoded, err := json.Marshal(entry)
+	if err != nil {
+		return
+	}
+	l.out.Write(append(encoded, '\n'))
+}
+
+func logDebug(msg string, fields ...field) { appLogger.log(LevelDebug, msg, fields) }
+func logInfo(msg string, fields ...field)  { appLogger.log(LevelInfo, msg, fields) }
+func logWarn(msg string, fields ...field)  { appLogger.log(LevelWarn, msg, fields) }
+func logError(msg string, fields ...field) { appLogger.log(LevelError, msg, fields) }
+
+// logMessage preserves the historical printf-style call sites used
+// throughout the codebase, logging the formatted message at INFO level
+// with no additional structured fields.
+func logMessage(format string, args ...any) {
+	logInfo(fmt.Sprintf(format, args...))
+}
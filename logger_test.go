@@ -0,0 +1,76 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestStructuredLoggerWritesLogfmt(t *testing.T) {
+	var buf bytes.Buffer
+	l := &structuredLogger{out: &buf, format: "logfmt"}
+
+	l.log(LevelWarn, "quorum failed", []field{kv("event", "quorum_failed"), kv("quorum", 2)})
+
+	line := buf.String()
+	if !strings.Contains(line, "level=WARN") {
+		t.Errorf("Expected level=WARN in output, got %q", line)
+	}
+	if !strings.Contains(line, `msg="quorum failed"`) {
+		t.Errorf("Expected quoted msg field in output, got %q", line)
+	}
+	if !strings.Contains(line, "event=quorum_failed") {
+		t.Errorf("Expected event field in output, got %q", line)
+	}
+	if !strings.Contains(line, "quorum=2") {
+		t.Errorf("Expected quorum field in output, got %q", line)
+	}
+}
+
+func TestStructuredLoggerWritesJSON(t *testing.T) {
+	var buf bytes.Buffer
+	l := &structuredLogger{out: &buf, format: "json"}
+
+	l.log(LevelInfo, "ip changed", []field{kv("event", "ip_change"), kv("old", "1.1.1.1"), kv("new", "2.2.2.2")})
+
+	var entry map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatalf("Expected valid JSON line, got error %v for %q", err, buf.String())
+	}
+	if entry["level"] != "INFO" {
+		t.Errorf("Expected level=INFO, got %v", entry["level"])
+	}
+	if entry["msg"] != "ip changed" {
+		t.Errorf("Expected msg=\"ip changed\", got %v", entry["msg"])
+	}
+	if entry["old"] != "1.1.1.1" || entry["new"] != "2.2.2.2" {
+		t.Errorf("Expected old/new fields to round-trip, got %v/%v", entry["old"], entry["new"])
+	}
+}
+
+func TestLogfmtValueQuotesWhenNeeded(t *testing.T) {
+	if got := logfmtValue("plain"); got != "plain" {
+		t.Errorf("Expected unquoted value for plain, got %q", got)
+	}
+	if got := logfmtValue("has space"); got != `"has space"` {
+		t.Errorf("Expected quoted value for string with a space, got %q", got)
+	}
+	if got := logfmtValue(""); got != `""` {
+		t.Errorf("Expected quoted empty value, got %q", got)
+	}
+}
+
+func TestConfigureLoggerSwitchesFormat(t *testing.T) {
+	var buf bytes.Buffer
+	configureLogger(&buf, "json")
+	defer configureLogger(os.Stderr, "logfmt")
+
+	logInfo("hello", kv("event", "test"))
+
+	var entry map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatalf("Expected configureLogger(\"json\") to produce JSON output, got error %v for %q", err, buf.String())
+	}
+}
@@ -1,27 +1,45 @@
 package main
 
 import (
-	"encoding/json"
 	"flag"
 	"fmt"
 	"io"
 	"log"
-	"net/http"
 	"os"
-	"path/filepath"
 	"sync"
 	"time"
 )
 
 // Config holds the application configuration
 type Config struct {
-	Interval        int    // Check interval in seconds
-	LogFile         string // Path to log file
-	IPEndpoint      string // Endpoint to check IP from
-	QuietMode       bool   // If true, only logs to file and not stdout
-	MaxRetries      int    // Maximum number of retry attempts for IP fetching
-	LastKnownIP     string // Cache the last known IP
+	Interval        int        // Check interval in seconds
+	LogFile         string     // Path to log file
+	Providers       []Provider // IP resolution providers to query each cycle
+	Quorum          int        // Minimum number of providers that must agree on an address
+	QuietMode       bool       // If true, only logs to file and not stdout
+	MaxRetries      int        // Maximum number of retry attempts for IP fetching
+	LastKnownIPv4   string     // Cache the last known IPv4 address
+	LastKnownIPv6   string     // Cache the last known IPv6 address
 	LastKnownIPLock sync.Mutex
+
+	RetryBaseDelay   time.Duration // Base delay for exponential backoff between retries
+	RetryMaxDelay    time.Duration // Upper bound on the backoff delay
+	RetryJitter      bool          // If true, apply full jitter to the backoff delay
+	BreakerThreshold int           // Consecutive failed check cycles before the circuit breaker opens
+
+	BreakerFailures  int       // Consecutive failed check cycles observed so far
+	BreakerOpenUntil time.Time // Zero if the breaker is closed, otherwise the time it reopens
+	BreakerLock      sync.Mutex
+
+	Notifiers   []Notifier           // Notifiers invoked whenever a last-known IP changes
+	NotifyQueue chan<- IPChangeEvent // Feeds the notifier worker pool; nil if no notifiers are configured
+
+	HTTPAddr string // Address to serve /healthz, /ip and /metrics on; disabled if empty
+
+	LogFormat     string // "logfmt" or "json"
+	LogMaxSizeMB  int    // Rotate the log file once it exceeds this size; 0 disables size-based rotation
+	LogMaxAgeDays int    // Delete rotated backups older than this many days; 0 disables age-based pruning
+	LogMaxBackups int    // Keep at most this many rotated backups; 0 keeps them all
 }
 
 func main() {
@@ -34,56 +52,113 @@ func main() {
 	// Create log file if specified
 	setupLogging(config)
 
+	// Start the notifier worker pool, if any notifiers are configured
+	if len(config.Notifiers) > 0 {
+		config.NotifyQueue = startNotifiers(config)
+	}
+
+	// Start the metrics/status HTTP server, if enabled
+	if config.HTTPAddr != "" {
+		startMetricsServer(config)
+	}
+
 	// Start the IP checking loop
 	logMessage("IP Watcher starting. Will check IP every %d seconds", config.Interval)
 	startIPChecker(config)
 }
 
-// parseFlags parses command-line flags and returns the configuration
+// parseFlags resolves the configuration by layering, in increasing order
+// of precedence: built-in defaults, a --config file (YAML or JSON),
+// IPW_-prefixed environment variables, and command-line flags. A flag the
+// user didn't actually type keeps whatever the earlier layers resolved to,
+// since it's registered with that value as its default.
 func parseFlags() *Config {
-	config := &Config{}
+	args := os.Args[1:]
+
+	raw := defaultRawSettings()
+	if path, ok := findFlagValue(args, "config"); ok {
+		if err := loadConfigFile(&raw, path); err != nil {
+			log.Printf("%v", err)
+			os.Exit(1)
+		}
+	}
+	loadEnvSettings(&raw)
 
-	flag.IntVar(&config.Interval, "interval", 60, "Interval between IP checks in seconds")
-	flag.StringVar(&config.LogFile, "log", "", "Log file path (if not specified, logs to stdout only)")
-	flag.StringVar(&config.IPEndpoint, "endpoint", "https://api64.ipify.org?format=json", "URL of the IP checking service")
-	flag.BoolVar(&config.QuietMode, "quiet", false, "If true, only logs to file and not stdout")
-	flag.IntVar(&config.MaxRetries, "max-retries", 5, "Maximum number of retry attempts when fetching external IP")
+	config := &Config{}
+	var endpoints endpointList
+	endpoints.urls = raw.Endpoint
+	var notifyURIs notifyList
+	notifyURIs.uris = raw.Notify
+
+	var configPath string
+	var dumpConfig bool
+
+	flag.StringVar(&configPath, "config", "", "Path to a YAML or JSON config file")
+	flag.BoolVar(&dumpConfig, "dump-config", false, "Print the effective resolved configuration as JSON and exit")
+	flag.IntVar(&config.Interval, "interval", parseIntSetting(raw.Interval, 60), "Interval between IP checks in seconds")
+	flag.StringVar(&config.LogFile, "log", raw.LogFile, "Log file path (if not specified, logs to stderr only)")
+	flag.Var(&endpoints, "endpoint", "URL of an IP checking service; may be repeated or comma-separated (default: built-in provider list)")
+	flag.IntVar(&config.Quorum, "quorum", parseIntSetting(raw.Quorum, 1), "Minimum number of providers that must agree on an address before it is accepted")
+	flag.BoolVar(&config.QuietMode, "quiet", parseBoolSetting(raw.QuietMode, false), "If true, only logs to file and not stdout")
+	flag.IntVar(&config.MaxRetries, "max-retries", parseIntSetting(raw.MaxRetries, 5), "Maximum number of retry attempts when fetching external IP")
+	flag.DurationVar(&config.RetryBaseDelay, "retry-base-delay", parseDurationSetting(raw.RetryBaseDelay, 500*time.Millisecond), "Base delay for exponential backoff between retries")
+	flag.DurationVar(&config.RetryMaxDelay, "retry-max-delay", parseDurationSetting(raw.RetryMaxDelay, 30*time.Second), "Maximum delay between retries")
+	flag.BoolVar(&config.RetryJitter, "retry-jitter", parseBoolSetting(raw.RetryJitter, true), "If true, apply full jitter to the backoff delay")
+	flag.IntVar(&config.BreakerThreshold, "breaker-threshold", parseIntSetting(raw.BreakerThreshold, 5), "Consecutive failed check cycles before the circuit breaker opens")
+	flag.Var(&notifyURIs, "notify", "Notifier URI to invoke on IP change; may be repeated (webhook+https://..., exec:///path, dyndns+https://...)")
+	flag.StringVar(&config.HTTPAddr, "http-addr", raw.HTTPAddr, "Address to serve /healthz, /ip and /metrics on (e.g. :9090); disabled if empty")
+	flag.StringVar(&config.LogFormat, "log-format", parseLogFormatSetting(raw.LogFormat, "logfmt"), "Structured log output format: logfmt or json")
+	flag.IntVar(&config.LogMaxSizeMB, "log-max-size-mb", parseIntSetting(raw.LogMaxSizeMB, 0), "Rotate the log file once it exceeds this size in MB; 0 disables size-based rotation")
+	flag.IntVar(&config.LogMaxAgeDays, "log-max-age-days", parseIntSetting(raw.LogMaxAgeDays, 0), "Delete rotated log backups older than this many days; 0 disables age-based pruning")
+	flag.IntVar(&config.LogMaxBackups, "log-max-backups", parseIntSetting(raw.LogMaxBackups, 0), "Keep at most this many rotated log backups; 0 keeps them all")
 
 	// Parse flags
 	flag.Parse()
 
-	return config
-}
+	if len(endpoints.urls) > 0 {
+		config.Providers = providersFromURLs(endpoints.urls)
+	} else {
+		config.Providers = DefaultProviders()
+	}
 
-// setupLogging configures logging to file if a log file is specified
-func setupLogging(config *Config) {
-	if config.LogFile != "" {
-		// Create directory for log file if it doesn't exist
-		logDir := filepath.Dir(config.LogFile)
-		if err := os.MkdirAll(logDir, 0755); err != nil {
-			log.Printf("Failed to create log directory: %v", err)
-			os.Exit(1)
-		}
+	notifiers, err := parseNotifiers(notifyURIs.uris)
+	if err != nil {
+		log.Printf("Invalid --notify configuration: %v", err)
+		os.Exit(1)
+	}
+	config.Notifiers = notifiers
 
-		// Open log file
-		logFile, err := os.OpenFile(config.LogFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
-		if err != nil {
-			log.Printf("Failed to open log file: %v", err)
+	if dumpConfig {
+		if err := printDumpedConfig(config, providerURLs(config.Providers), notifyURIs.uris); err != nil {
+			log.Printf("Failed to dump config: %v", err)
 			os.Exit(1)
 		}
-
-		// Set log output to the file
-		if config.QuietMode {
-			log.SetOutput(logFile)
-		} else {
-			log.SetOutput(io.MultiWriter(os.Stdout, logFile))
-		}
+		os.Exit(0)
 	}
+
+	return config
 }
 
-// logMessage logs a message to the configured output
-func logMessage(format string, args ...any) {
-	log.Printf(format, args...)
+// setupLogging points the global structured logger at config.LogFile (with
+// size/age/backup-bounded rotation, if configured), or points it at stderr
+// if no log file is specified.
+func setupLogging(config *Config) {
+	if config.LogFile == "" {
+		configureLogger(os.Stderr, config.LogFormat)
+		return
+	}
+
+	logFile, err := newRotatingWriter(config.LogFile, config.LogMaxSizeMB, config.LogMaxAgeDays, config.LogMaxBackups)
+	if err != nil {
+		log.Printf("Failed to open log file: %v", err)
+		os.Exit(1)
+	}
+
+	if config.QuietMode {
+		configureLogger(logFile, config.LogFormat)
+	} else {
+		configureLogger(io.MultiWriter(os.Stdout, logFile), config.LogFormat)
+	}
 }
 
 // startIPChecker starts the main loop checking for IP changes
@@ -100,79 +175,62 @@ func startIPChecker(config *Config) {
 	}
 }
 
-// checkIP performs a single IP check
+// checkIP performs a single IP check, querying all configured providers and
+// accepting a per-family address only once config.Quorum of them agree.
 func checkIP(config *Config) {
-	// Make HTTP request to get IP
-	ip, err := fetchExternalIP(config.IPEndpoint, 1, config.MaxRetries)
-	if err != nil {
-		logMessage("Error checking IP: %v", err)
+	now := time.Now().Unix()
+	metrics.recordCheck(now)
+
+	if open, until := breakerOpen(config); open {
+		logWarn(fmt.Sprintf("Circuit breaker open, skipping check until %s", until.Format(time.RFC3339)),
+			kv("event", "breaker_skip"), kv("open_until", until.Format(time.RFC3339)))
 		return
 	}
 
-	// Compare with last known IP
+	agreed := resolveIPs(config)
+	if len(agreed) == 0 {
+		recordBreakerFailure(config)
+		metrics.recordCheckFailure("quorum")
+		logWarn(fmt.Sprintf("Error checking IP: no provider quorum of %d reached", effectiveQuorum(config)),
+			kv("event", "quorum_failed"), kv("quorum", effectiveQuorum(config)))
+		return
+	}
+	recordBreakerSuccess(config)
+	metrics.recordCheckSuccess(now)
+
+	// Compare with last known IPs, per address family
 	config.LastKnownIPLock.Lock()
 	defer config.LastKnownIPLock.Unlock()
 
-	if ip != config.LastKnownIP {
-		if config.LastKnownIP == "" {
-			logMessage("Current external IP: %s", ip)
-		} else {
-			logMessage("IP changed: %s -> %s", config.LastKnownIP, ip)
-		}
-		config.LastKnownIP = ip
+	if ip, ok := agreed["ipv4"]; ok {
+		reportIPChange(config, "IPv4", &config.LastKnownIPv4, ip)
+	}
+	if ip, ok := agreed["ipv6"]; ok {
+		reportIPChange(config, "IPv6", &config.LastKnownIPv6, ip)
 	}
 }
 
+// reportIPChange logs and records a newly agreed-upon address for one
+// address family, if it differs from the previously known value, and
+// notifies any configured notifiers of the change.
+func reportIPChange(config *Config, family string, last *string, ip string) {
+	if ip == *last {
+		return
+	}
+	old := *last
+	if old == "" {
+		logInfo(fmt.Sprintf("Current external %s: %s", family, ip),
+			kv("event", "ip_change"), kv("family", family), kv("old", old), kv("new", ip))
+	} else {
+		logInfo(fmt.Sprintf("%s changed: %s -> %s", family, old, ip),
+			kv("event", "ip_change"), kv("family", family), kv("old", old), kv("new", ip))
+	}
+	*last = ip
+	metrics.recordIPChange(time.Now().Unix())
+	dispatchIPChange(config, family, old, ip)
+}
+
 // Response from ipify API
 type IPifyResponse struct {
 	IP string `json:"ip"`
 }
-
-// fetchExternalIP makes an HTTP request to the specified endpoint and extracts the IP
-// It will retry up to maxAttempts times if the request fails
-func fetchExternalIP(endpoint string, attempt int, maxAttempts int) (string, error) {
-	// Create HTTP client with a timeout
-	client := http.Client{
-		Timeout: 5 * time.Second,
-	}
-	
-	// If we've already reached max attempts, fail immediately
-	if attempt > maxAttempts {
-		return "", fmt.Errorf("failed to make request after %d attempts", maxAttempts)
-	}
-	
-	// Make request
-	resp, err := client.Get(endpoint)
-	if err != nil {
-		// If we haven't reached max attempts yet, try again
-		if attempt < maxAttempts {
-			return fetchExternalIP(endpoint, attempt+1, maxAttempts)
-		}
-		return "", fmt.Errorf("failed to make request after %d attempts: %v", maxAttempts, err)
-	}
-	defer resp.Body.Close()
-	
-	// Check status code - only proceed with 2xx responses
-	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		// If we haven't reached max attempts yet, try again
-		if attempt < maxAttempts {
-			return fetchExternalIP(endpoint, attempt+1, maxAttempts)
-		}
-		return "", fmt.Errorf("failed after %d attempts: HTTP status %d", maxAttempts, resp.StatusCode)
-	}
-	
-	// Read response
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return "", fmt.Errorf("failed to read response: %v", err)
-	}
-	
-	// Try to parse as JSON format (e.g., from ipify)
-	var ipResponse IPifyResponse
-	if err := json.Unmarshal(body, &ipResponse); err == nil && ipResponse.IP != "" {
-		return ipResponse.IP, nil
-	}
-	
-	// If JSON parsing fails, assume response is plain text
-	return string(body), nil
-}
@@ -6,7 +6,6 @@ import (
 	"encoding/json"
 	"flag"
 	"io"
-	"log"
 	"net/http"
 	"net/http/httptest"
 	"os"
@@ -20,19 +19,27 @@ func TestParseFlags(t *testing.T) {
 	// Create a separate implementation of parseFlags for testing to avoid flag redefinition
 	testParseFlags := func(args []string) *Config {
 		config := &Config{}
+		var endpoints endpointList
 
 		// Create a new FlagSet for testing
 		fs := flag.NewFlagSet("test", flag.ExitOnError)
 
 		fs.IntVar(&config.Interval, "interval", 300, "Interval between IP checks in seconds")
 		fs.StringVar(&config.LogFile, "log", "", "Log file path (if not specified, logs to stdout only)")
-		fs.StringVar(&config.IPEndpoint, "endpoint", "https://api.ipify.org?format=json", "URL of the IP checking service")
+		fs.Var(&endpoints, "endpoint", "URL of an IP checking service; may be repeated or comma-separated")
+		fs.IntVar(&config.Quorum, "quorum", 1, "Minimum number of providers that must agree on an address")
 		fs.BoolVar(&config.QuietMode, "quiet", false, "If true, only logs to file and not stdout")
 		fs.IntVar(&config.MaxRetries, "max-retries", 5, "Maximum number of retry attempts when fetching external IP")
 
 		// Parse the test args
 		fs.Parse(args)
 
+		if len(endpoints.urls) > 0 {
+			config.Providers = providersFromURLs(endpoints.urls)
+		} else {
+			config.Providers = DefaultProviders()
+		}
+
 		return config
 	}
 
@@ -44,8 +51,11 @@ func TestParseFlags(t *testing.T) {
 	if config.LogFile != "" {
 		t.Errorf("Expected default log file to be empty, got %s", config.LogFile)
 	}
-	if !strings.Contains(config.IPEndpoint, "ipify.org") {
-		t.Errorf("Expected default endpoint to contain ipify.org, got %s", config.IPEndpoint)
+	if len(config.Providers) == 0 {
+		t.Error("Expected default providers to be populated")
+	}
+	if config.Quorum != 1 {
+		t.Errorf("Expected default quorum to be 1, got %d", config.Quorum)
 	}
 	if config.QuietMode {
 		t.Errorf("Expected default quiet mode to be false, got %v", config.QuietMode)
@@ -55,7 +65,7 @@ func TestParseFlags(t *testing.T) {
 	}
 
 	// Test with custom values
-	config = testParseFlags([]string{"-interval=60", "-log=/tmp/test.log", "-quiet=true", "-max-retries=3"})
+	config = testParseFlags([]string{"-interval=60", "-log=/tmp/test.log", "-quiet=true", "-max-retries=3", "-endpoint=https://a.example.com,https://b.example.com", "-quorum=2"})
 	if config.Interval != 60 {
 		t.Errorf("Expected interval to be 60, got %d", config.Interval)
 	}
@@ -68,6 +78,21 @@ func TestParseFlags(t *testing.T) {
 	if config.MaxRetries != 3 {
 		t.Errorf("Expected max retries to be 3, got %d", config.MaxRetries)
 	}
+	if len(config.Providers) != 2 {
+		t.Errorf("Expected 2 providers from comma-separated endpoints, got %d", len(config.Providers))
+	}
+	if config.Quorum != 2 {
+		t.Errorf("Expected quorum to be 2, got %d", config.Quorum)
+	}
+}
+
+func testRetryConfig(maxRetries int) *Config {
+	return &Config{
+		MaxRetries:     maxRetries,
+		RetryBaseDelay: time.Millisecond,
+		RetryMaxDelay:  5 * time.Millisecond,
+		RetryJitter:    false,
+	}
 }
 
 func TestFetchExternalIP(t *testing.T) {
@@ -80,7 +105,7 @@ func TestFetchExternalIP(t *testing.T) {
 	}))
 	defer testServer.Close()
 
-	ip, err := fetchExternalIP(testServer.URL, 1, 5)
+	ip, err := fetchExternalIP(testRetryConfig(5), testServer.URL)
 	if err != nil {
 		t.Fatalf("Expected no error, got %v", err)
 	}
@@ -95,7 +120,7 @@ func TestFetchExternalIP(t *testing.T) {
 	}))
 	defer testServer2.Close()
 
-	ip, err = fetchExternalIP(testServer2.URL, 1, 5)
+	ip, err = fetchExternalIP(testRetryConfig(5), testServer2.URL)
 	if err != nil {
 		t.Fatalf("Expected no error, got %v", err)
 	}
@@ -104,7 +129,7 @@ func TestFetchExternalIP(t *testing.T) {
 	}
 
 	// Test case 3: Error scenario - this will attempt to retry but eventually fail
-	ip, err = fetchExternalIP("http://nonexistent.example.com", 1, 5)
+	ip, err = fetchExternalIP(testRetryConfig(5), "http://nonexistent.example.com")
 	if err == nil {
 		t.Error("Expected error for non-existent URL, got none")
 	}
@@ -123,19 +148,20 @@ func TestCheckIP(t *testing.T) {
 
 	// Capture log output
 	var buf bytes.Buffer
-	log.SetOutput(&buf)
-	defer func() { log.SetOutput(os.Stderr) }()
+	configureLogger(&buf, "logfmt")
+	defer configureLogger(os.Stderr, "logfmt")
 
 	config := &Config{
-		IPEndpoint: testServer.URL,
+		Providers:  providersFromURLs([]string{testServer.URL}),
+		Quorum:     1,
 		MaxRetries: 5,
 	}
 
 	// First check should log the IP
 	checkIP(config)
 	output := buf.String()
-	if !strings.Contains(output, "Current external IP: 1.2.3.4") {
-		t.Errorf("Expected log to contain 'Current external IP: 1.2.3.4', got: %s", output)
+	if !strings.Contains(output, "Current external IPv4: 1.2.3.4") {
+		t.Errorf("Expected log to contain 'Current external IPv4: 1.2.3.4', got: %s", output)
 	}
 
 	// Reset buffer
@@ -153,7 +179,7 @@ func TestCheckIP(t *testing.T) {
 	serverIP = "5.6.7.8"
 	checkIP(config)
 	output = buf.String()
-	if !strings.Contains(output, "IP changed: 1.2.3.4 -> 5.6.7.8") {
+	if !strings.Contains(output, "IPv4 changed: 1.2.3.4 -> 5.6.7.8") {
 		t.Errorf("Expected log to contain IP change message, got: %s", output)
 	}
 }
@@ -196,7 +222,7 @@ func TestFetchExternalIPWithStatusCodeRetry(t *testing.T) {
 			w.WriteHeader(http.StatusInternalServerError)
 			return
 		}
-	
+
 		// After max fails, return success
 		response := IPifyResponse{IP: "192.168.1.100"}
 		jsonBytes, _ := json.Marshal(response)
@@ -206,7 +232,7 @@ func TestFetchExternalIPWithStatusCodeRetry(t *testing.T) {
 	defer testServer.Close()
 
 	// Should succeed after retries
-	ip, err := fetchExternalIP(testServer.URL, 1, 5)
+	ip, err := fetchExternalIP(testRetryConfig(5), testServer.URL)
 
 	if err != nil {
 		t.Fatalf("Expected success after retries, got error: %v", err)
@@ -228,7 +254,7 @@ func TestFetchExternalIPWithStatusCodeRetry(t *testing.T) {
 	defer alwaysFailServer.Close()
 
 	// Should fail after all retries
-	_, err = fetchExternalIP(alwaysFailServer.URL, 1, 5)
+	_, err = fetchExternalIP(testRetryConfig(5), alwaysFailServer.URL)
 	if err == nil {
 		t.Error("Expected error after max retries, got nil")
 	}
@@ -237,7 +263,7 @@ func TestFetchExternalIPWithStatusCodeRetry(t *testing.T) {
 // TestFetchExternalIPWithNetworkErrorRetry tests the retry functionality with network errors
 func TestFetchExternalIPWithNetworkErrorRetry(t *testing.T) {
 	// Test with a non-existent URL that will cause a network error
-	_, err := fetchExternalIP("http://non.existent.server.local", 1, 5)
+	_, err := fetchExternalIP(testRetryConfig(5), "http://non.existent.server.local")
 
 	if err == nil {
 		t.Error("Expected error for network failure, got none")
@@ -253,40 +279,40 @@ func TestFetchExternalIPWithNetworkErrorRetry(t *testing.T) {
 func TestFetchExternalIPWithConfigurableRetries(t *testing.T) {
 	// Counter for tracking the number of requests
 	requestCount := 0
-	
+
 	// Create a test server that always fails with a 500 status
 	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		requestCount++
 		w.WriteHeader(http.StatusInternalServerError)
 	}))
 	defer testServer.Close()
-	
+
 	// Test with max retries = 3
 	maxRetries := 3
-	_, err := fetchExternalIP(testServer.URL, 1, maxRetries)
-	
+	_, err := fetchExternalIP(testRetryConfig(maxRetries), testServer.URL)
+
 	// Should fail
 	if err == nil {
 		t.Error("Expected error when all retries fail")
 	}
-	
+
 	// Should have made exactly maxRetries attempts
 	if requestCount != maxRetries {
 		t.Errorf("Expected %d requests, got %d", maxRetries, requestCount)
 	}
-	
+
 	// Reset counter
 	requestCount = 0
-	
+
 	// Test with different max retries
 	maxRetries = 2
-	_, err = fetchExternalIP(testServer.URL, 1, maxRetries)
-	
+	_, err = fetchExternalIP(testRetryConfig(maxRetries), testServer.URL)
+
 	// Should still fail
 	if err == nil {
 		t.Error("Expected error when all retries fail")
 	}
-	
+
 	// Should have made exactly the new maxRetries attempts
 	if requestCount != maxRetries {
 		t.Errorf("Expected %d requests, got %d", maxRetries, requestCount)
@@ -322,14 +348,14 @@ func TestIPLoggerIntegration(t *testing.T) {
 	// Set up config for test
 	config := &Config{
 		Interval:   1, // 1 second interval for faster testing
-		IPEndpoint: testServer.URL,
+		Providers:  providersFromURLs([]string{testServer.URL}),
+		Quorum:     1,
 		MaxRetries: 5,
 	}
 
 	// Capture log output
-	oldOutput := log.Writer()
 	r, w := io.Pipe()
-	log.SetOutput(w)
+	configureLogger(w, "logfmt")
 
 	// Set up done channel with a buffer to prevent blocking
 	done := make(chan bool, 1)
@@ -349,11 +375,11 @@ func TestIPLoggerIntegration(t *testing.T) {
 			if n > 0 {
 				outputStr = string(buf[:n])
 
-				if strings.Contains(outputStr, "Current external IP: 192.168.0.1") {
+				if strings.Contains(outputStr, "Current external IPv4: 192.168.0.1") {
 					// Found initial IP message
 				}
 
-				if strings.Contains(outputStr, "IP changed: 192.168.0.1 -> 192.168.0.2") {
+				if strings.Contains(outputStr, "IPv4 changed: 192.168.0.1 -> 192.168.0.2") {
 					// Found IP change message
 					done <- true
 					return
@@ -396,5 +422,5 @@ func TestIPLoggerIntegration(t *testing.T) {
 
 	// Restore original log output and close the pipe
 	w.Close()
-	log.SetOutput(oldOutput)
+	configureLogger(os.Stderr, "logfmt")
 }
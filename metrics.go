@@ -0,0 +1,140 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+	"sync/atomic"
+)
+
+// metricsRegistry accumulates the counters, gauges, and a histogram served
+// by /metrics, in Prometheus text exposition format. It intentionally has
+// no dependency on the Prometheus client library - a package-level
+// registry and a small text writer is all this program needs.
+type metricsRegistry struct {
+	checksTotal          int64
+	ipChangesTotal       int64
+	lastCheckTimestamp   int64 // unix seconds, 0 if no check has run yet
+	lastSuccessTimestamp int64 // unix seconds, 0 if no check has succeeded yet
+	lastChangeTimestamp  int64 // unix seconds, 0 if no change has been observed yet
+
+	failuresMu       sync.Mutex
+	failuresByReason map[string]int64
+
+	retryAttempts *histogram
+}
+
+var metrics = newMetricsRegistry()
+
+func newMetricsRegistry() *metricsRegistry {
+	return &metricsRegistry{
+		failuresByReason: make(map[string]int64),
+		retryAttempts:    newHistogram([]float64{1, 2, 3, 5, 8, 13, 21}),
+	}
+}
+
+func (m *metricsRegistry) recordCheck(now int64) {
+	atomic.AddInt64(&m.checksTotal, 1)
+	atomic.StoreInt64(&m.lastCheckTimestamp, now)
+}
+
+func (m *metricsRegistry) recordCheckSuccess(now int64) {
+	atomic.StoreInt64(&m.lastSuccessTimestamp, now)
+}
+
+func (m *metricsRegistry) recordCheckFailure(reason string) {
+	m.failuresMu.Lock()
+	defer m.failuresMu.Unlock()
+	m.failuresByReason[reason]++
+}
+
+func (m *metricsRegistry) recordIPChange(now int64) {
+	atomic.AddInt64(&m.ipChangesTotal, 1)
+	atomic.StoreInt64(&m.lastChangeTimestamp, now)
+}
+
+func (m *metricsRegistry) recordRetryAttempts(attempts int) {
+	m.retryAttempts.observe(float64(attempts))
+}
+
+// writeTo renders the registry in Prometheus text exposition format.
+func (m *metricsRegistry) writeTo(w io.Writer) {
+	fmt.Fprintf(w, "# HELP ipwatcher_checks_total Total number of IP check cycles run.\n")
+	fmt.Fprintf(w, "# TYPE ipwatcher_checks_total counter\n")
+	fmt.Fprintf(w, "ipwatcher_checks_total %d\n", atomic.LoadInt64(&m.checksTotal))
+
+	fmt.Fprintf(w, "# HELP ipwatcher_check_failures_total Total number of failed IP check cycles, by reason.\n")
+	fmt.Fprintf(w, "# TYPE ipwatcher_check_failures_total counter\n")
+	m.failuresMu.Lock()
+	reasons := make([]string, 0, len(m.failuresByReason))
+	for reason := range m.failuresByReason {
+		reasons = append(reasons, reason)
+	}
+	sort.Strings(reasons)
+	for _, reason := range reasons {
+		fmt.Fprintf(w, "ipwatcher_check_failures_total{reason=%q} %d\n", reason, m.failuresByReason[reason])
+	}
+	m.failuresMu.Unlock()
+
+	fmt.Fprintf(w, "# HELP ipwatcher_ip_changes_total Total number of detected IP address changes.\n")
+	fmt.Fprintf(w, "# TYPE ipwatcher_ip_changes_total counter\n")
+	fmt.Fprintf(w, "ipwatcher_ip_changes_total %d\n", atomic.LoadInt64(&m.ipChangesTotal))
+
+	fmt.Fprintf(w, "# HELP ipwatcher_last_check_timestamp_seconds Unix time of the last check cycle.\n")
+	fmt.Fprintf(w, "# TYPE ipwatcher_last_check_timestamp_seconds gauge\n")
+	fmt.Fprintf(w, "ipwatcher_last_check_timestamp_seconds %d\n", atomic.LoadInt64(&m.lastCheckTimestamp))
+
+	fmt.Fprintf(w, "# HELP ipwatcher_last_change_timestamp_seconds Unix time of the last detected IP change.\n")
+	fmt.Fprintf(w, "# TYPE ipwatcher_last_change_timestamp_seconds gauge\n")
+	fmt.Fprintf(w, "ipwatcher_last_change_timestamp_seconds %d\n", atomic.LoadInt64(&m.lastChangeTimestamp))
+
+	fmt.Fprintf(w, "# HELP ipwatcher_retry_attempts Number of HTTP attempts a single IP fetch took.\n")
+	fmt.Fprintf(w, "# TYPE ipwatcher_retry_attempts histogram\n")
+	m.retryAttempts.writeTo(w, "ipwatcher_retry_attempts")
+}
+
+// histogram is a minimal fixed-bucket Prometheus-style histogram.
+type histogram struct {
+	mu      sync.Mutex
+	buckets []float64 // upper bounds, ascending
+	counts  []int64   // counts[i] = number of observations <= buckets[i]
+	sum     float64
+	count   int64
+}
+
+func newHistogram(buckets []float64) *histogram {
+	return &histogram{buckets: buckets, counts: make([]int64, len(buckets))}
+}
+
+func (h *histogram) observe(v float64) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.sum += v
+	h.count++
+	for i, bound := range h.buckets {
+		if v <= bound {
+			h.counts[i]++
+		}
+	}
+}
+
+func (h *histogram) writeTo(w io.Writer, name string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for i, bound := range h.buckets {
+		fmt.Fprintf(w, "%s_bucket{le=%q} %d\n", name, formatBound(bound), h.counts[i])
+	}
+	fmt.Fprintf(w, "%s_bucket{le=\"+Inf\"} %d\n", name, h.count)
+	fmt.Fprintf(w, "%s_sum %v\n", name, h.sum)
+	fmt.Fprintf(w, "%s_count %d\n", name, h.count)
+}
+
+func formatBound(bound float64) string {
+	if bound == float64(int64(bound)) {
+		return fmt.Sprintf("%d", int64(bound))
+	}
+	return fmt.Sprintf("%g", bound)
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+// resetMetricsForTest swaps in a fresh metrics registry so tests don't
+// observe counts left behind by other tests sharing the package-level
+// metrics var.
+func resetMetricsForTest() {
+	metrics = newMetricsRegistry()
+}
+
+func TestHistogramObserveAndRender(t *testing.T) {
+	h := newHistogram([]float64{1, 2, 5})
+	h.observe(1)
+	h.observe(2)
+	h.observe(4)
+
+	var buf bytes.Buffer
+	h.writeTo(&buf, "test_hist")
+	out := buf.String()
+
+	if !strings.Contains(out, `test_hist_bucket{le="1"} 1`) {
+		t.Errorf("Expected le=1 bucket of 1, got:\n%s", out)
+	}
+	if !strings.Contains(out, `test_hist_bucket{le="2"} 2`) {
+		t.Errorf("Expected le=2 bucket of 2, got:\n%s", out)
+	}
+	if !strings.Contains(out, `test_hist_bucket{le="5"} 3`) {
+		t.Errorf("Expected le=5 bucket of 3, got:\n%s", out)
+	}
+	if !strings.Contains(out, `test_hist_bucket{le="+Inf"} 3`) {
+		t.Errorf("Expected +Inf bucket of 3, got:\n%s", out)
+	}
+	if !strings.Contains(out, "test_hist_count 3") {
+		t.Errorf("Expected count of 3, got:\n%s", out)
+	}
+}
+
+func TestMetricsRegistryChecksAndFailures(t *testing.T) {
+	resetMetricsForTest()
+
+	metrics.recordCheck(100)
+	metrics.recordCheck(160)
+	metrics.recordCheckFailure("quorum")
+	metrics.recordCheckSuccess(160)
+	metrics.recordIPChange(160)
+
+	var buf bytes.Buffer
+	metrics.writeTo(&buf)
+	out := buf.String()
+
+	if !strings.Contains(out, "ipwatcher_checks_total 2") {
+		t.Errorf("Expected 2 checks recorded, got:\n%s", out)
+	}
+	if !strings.Contains(out, `ipwatcher_check_failures_total{reason="quorum"} 1`) {
+		t.Errorf("Expected 1 quorum failure recorded, got:\n%s", out)
+	}
+	if !strings.Contains(out, "ipwatcher_ip_changes_total 1") {
+		t.Errorf("Expected 1 IP change recorded, got:\n%s", out)
+	}
+	if !strings.Contains(out, "ipwatcher_last_check_timestamp_seconds 160") {
+		t.Errorf("Expected last check timestamp of 160, got:\n%s", out)
+	}
+	if !strings.Contains(out, "ipwatcher_last_change_timestamp_seconds 160") {
+		t.Errorf("Expected last change timestamp of 160, got:\n%s", out)
+	}
+}
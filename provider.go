@@ -0,0 +1,189 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+)
+
+// Provider describes a single external IP-resolution endpoint.
+type Provider struct {
+	Name   string // Human-readable identifier, used in logs
+	URL    string // Endpoint to query
+	Format string // Response format: "json" (e.g. {"ip":"1.2.3.4"}) or "plain"
+	Family string // Expected address family: "ipv4" or "ipv6"; informational only, the resolved family is derived from the parsed address
+}
+
+// DefaultProviders returns the built-in provider list used when no
+// --endpoint flags are supplied.
+func DefaultProviders() []Provider {
+	return []Provider{
+		{Name: "ipify-v4", URL: "https://api.ipify.org?format=json", Format: "json", Family: "ipv4"},
+		{Name: "ipify-v6", URL: "https://api6.ipify.org?format=json", Format: "json", Family: "ipv6"},
+		{Name: "icanhazip", URL: "https://icanhazip.com", Format: "plain", Family: "ipv4"},
+		{Name: "ifconfig.me", URL: "https://ifconfig.me/ip", Format: "plain", Family: "ipv4"},
+	}
+}
+
+// providersFromURLs builds a provider list from user-supplied --endpoint
+// URLs. The format is auto-detected at fetch time and the family is
+// determined from the resolved address, so neither needs to be known here.
+func providersFromURLs(urls []string) []Provider {
+	providers := make([]Provider, 0, len(urls))
+	for i, u := range urls {
+		providers = append(providers, Provider{
+			Name: fmt.Sprintf("endpoint-%d", i+1),
+			URL:  u,
+		})
+	}
+	return providers
+}
+
+// endpointList implements flag.Value, collecting one or more --endpoint
+// values (repeated and/or comma-separated) into a slice of URLs. It may be
+// pre-seeded (e.g. from a config file or environment variable); the first
+// call to Set from an actual command-line flag discards the pre-seeded
+// values so the flag fully overrides the earlier layers rather than
+// appending to them.
+type endpointList struct {
+	urls          []string
+	explicitlySet bool
+}
+
+func (e *endpointList) String() string {
+	return strings.Join(e.urls, ",")
+}
+
+func (e *endpointList) Set(value string) error {
+	if !e.explicitlySet {
+		e.urls = nil
+		e.explicitlySet = true
+	}
+	for _, part := range strings.Split(value, ",") {
+		part = strings.TrimSpace(part)
+		if part != "" {
+			e.urls = append(e.urls, part)
+		}
+	}
+	return nil
+}
+
+// providerURLs extracts the endpoint URL from each provider, in order.
+func providerURLs(providers []Provider) []string {
+	urls := make([]string, len(providers))
+	for i, p := range providers {
+		urls[i] = p.URL
+	}
+	return urls
+}
+
+// providerResult is the outcome of querying a single provider.
+type providerResult struct {
+	provider Provider
+	family   string
+	ip       string
+	err      error
+}
+
+// fetchProviderIP resolves a single provider's address using the existing
+// retry/backoff policy, then validates it as a real IP address.
+func fetchProviderIP(config *Config, p Provider) (net.IP, error) {
+	raw, err := fetchExternalIP(config, p.URL)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", p.Name, err)
+	}
+
+	ip := net.ParseIP(strings.TrimSpace(raw))
+	if ip == nil {
+		return nil, fmt.Errorf("%s: invalid address %q", p.Name, raw)
+	}
+	return ip, nil
+}
+
+// queryProviders fetches an address from every configured provider
+// concurrently and classifies each by resolved address family.
+func queryProviders(config *Config) []providerResult {
+	results := make([]providerResult, len(config.Providers))
+
+	var wg sync.WaitGroup
+	for i, p := range config.Providers {
+		wg.Add(1)
+		go func(i int, p Provider) {
+			defer wg.Done()
+
+			ip, err := fetchProviderIP(config, p)
+			if err != nil {
+				results[i] = providerResult{provider: p, err: err}
+				return
+			}
+
+			family := "ipv4"
+			if ip.To4() == nil {
+				family = "ipv6"
+			}
+			results[i] = providerResult{provider: p, family: family, ip: ip.String()}
+		}(i, p)
+	}
+	wg.Wait()
+
+	return results
+}
+
+// effectiveQuorum returns the configured quorum, defaulting to 1 (i.e. any
+// single provider's answer is trusted) when unset or invalid.
+func effectiveQuorum(config *Config) int {
+	if config.Quorum < 1 {
+		return 1
+	}
+	return config.Quorum
+}
+
+// resolveIPs queries all configured providers and returns, for each address
+// family with at least one response, the address that effectiveQuorum
+// providers agreed on. A family is omitted entirely if no address reached
+// quorum. When more than one distinct address reaches quorum for the same
+// family, the one with the most agreeing providers wins; ties are broken
+// by whichever address was first reported, in config.Providers order, so
+// the outcome is stable across calls on identical data rather than
+// depending on Go's randomized map iteration.
+func resolveIPs(config *Config) map[string]string {
+	quorum := effectiveQuorum(config)
+	counts := make(map[string]map[string]int) // family -> ip -> agreeing providers
+	order := make(map[string][]string)        // family -> ips in first-reported order
+
+	for _, r := range queryProviders(config) {
+		if r.err != nil {
+			logWarn(fmt.Sprintf("Provider %s failed: %v", r.provider.Name, r.err),
+				kv("event", "provider_fetch_failed"), kv("provider", r.provider.Name), kv("err", r.err.Error()))
+			continue
+		}
+		if counts[r.family] == nil {
+			counts[r.family] = make(map[string]int)
+		}
+		if counts[r.family][r.ip] == 0 {
+			order[r.family] = append(order[r.family], r.ip)
+		}
+		counts[r.family][r.ip]++
+	}
+
+	agreed := make(map[string]string)
+	for family, ips := range counts {
+		best := ""
+		bestCount := 0
+		for _, ip := range order[family] {
+			if ips[ip] > bestCount {
+				best = ip
+				bestCount = ips[ip]
+			}
+		}
+		if bestCount >= quorum {
+			agreed[family] = best
+		} else {
+			logWarn(fmt.Sprintf("No quorum of %d reached for %s (got %d distinct answers)", quorum, family, len(ips)),
+				kv("event", "quorum_failed"), kv("family", family), kv("quorum", quorum), kv("distinct_answers", len(ips)))
+		}
+	}
+
+	return agreed
+}
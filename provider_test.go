@@ -0,0 +1,105 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func ipServer(ip string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		response := IPifyResponse{IP: ip}
+		jsonBytes, _ := json.Marshal(response)
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(jsonBytes)
+	}))
+}
+
+func quorumTestConfig(urls []string, quorum int) *Config {
+	return &Config{
+		Providers:      providersFromURLs(urls),
+		Quorum:         quorum,
+		MaxRetries:     1,
+		RetryBaseDelay: time.Millisecond,
+		RetryMaxDelay:  time.Millisecond,
+	}
+}
+
+func TestResolveIPsQuorumSuccess(t *testing.T) {
+	s1 := ipServer("1.2.3.4")
+	defer s1.Close()
+	s2 := ipServer("1.2.3.4")
+	defer s2.Close()
+	s3 := ipServer("9.9.9.9")
+	defer s3.Close()
+
+	config := quorumTestConfig([]string{s1.URL, s2.URL, s3.URL}, 2)
+	agreed := resolveIPs(config)
+
+	if agreed["ipv4"] != "1.2.3.4" {
+		t.Errorf("Expected quorum to agree on 1.2.3.4, got %q", agreed["ipv4"])
+	}
+}
+
+func TestResolveIPsQuorumFailure(t *testing.T) {
+	s1 := ipServer("1.2.3.4")
+	defer s1.Close()
+	s2 := ipServer("5.6.7.8")
+	defer s2.Close()
+	s3 := ipServer("9.9.9.9")
+	defer s3.Close()
+
+	config := quorumTestConfig([]string{s1.URL, s2.URL, s3.URL}, 2)
+	agreed := resolveIPs(config)
+
+	if _, ok := agreed["ipv4"]; ok {
+		t.Errorf("Expected no quorum when all providers disagree, got %q", agreed["ipv4"])
+	}
+}
+
+func TestResolveIPsDisagreementWithUnreachableProvider(t *testing.T) {
+	s1 := ipServer("1.2.3.4")
+	defer s1.Close()
+
+	config := quorumTestConfig([]string{s1.URL, "http://nonexistent.invalid.example"}, 2)
+	agreed := resolveIPs(config)
+
+	if _, ok := agreed["ipv4"]; ok {
+		t.Errorf("Expected no quorum when one provider is unreachable, got %q", agreed["ipv4"])
+	}
+}
+
+func TestResolveIPsTiedQuorumIsDeterministic(t *testing.T) {
+	s1 := ipServer("1.2.3.4")
+	defer s1.Close()
+	s2 := ipServer("9.9.9.9")
+	defer s2.Close()
+
+	config := quorumTestConfig([]string{s1.URL, s2.URL}, 1)
+
+	for i := 0; i < 20; i++ {
+		agreed := resolveIPs(config)
+		if agreed["ipv4"] != "1.2.3.4" {
+			t.Fatalf("Expected tie to resolve to the first-reporting provider's address 1.2.3.4 on every call, got %q on iteration %d", agreed["ipv4"], i)
+		}
+	}
+}
+
+func TestCheckIPTracksIPv4AndIPv6Independently(t *testing.T) {
+	v4 := ipServer("1.2.3.4")
+	defer v4.Close()
+	v6 := ipServer("2001:db8::1")
+	defer v6.Close()
+
+	config := quorumTestConfig([]string{v4.URL, v6.URL}, 1)
+	checkIP(config)
+
+	if config.LastKnownIPv4 != "1.2.3.4" {
+		t.Errorf("Expected LastKnownIPv4 to be 1.2.3.4, got %q", config.LastKnownIPv4)
+	}
+	if config.LastKnownIPv6 != "2001:db8::1" {
+		t.Errorf("Expected LastKnownIPv6 to be 2001:db8::1, got %q", config.LastKnownIPv6)
+	}
+}
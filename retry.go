@@ -0,0 +1,178 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// fetchExternalIP makes an HTTP request to the endpoint and extracts the IP.
+// It makes up to config.MaxRetries attempts, using exponential backoff with
+// full jitter, failing fast on non-retryable 4xx responses. The number of
+// attempts a fetch took is recorded in the package-level metrics registry;
+// the pass/fail outcome of the overall check cycle is recorded by checkIP,
+// so that ipwatcher_check_failures_total stays one increment per cycle
+// rather than one per provider.
+func fetchExternalIP(config *Config, endpoint string) (string, error) {
+	client := http.Client{
+		Timeout: 5 * time.Second,
+	}
+
+	var lastErr error
+
+	for attempt := 1; attempt <= config.MaxRetries; attempt++ {
+		if attempt > 1 {
+			time.Sleep(backoffDelay(config, attempt-1))
+		}
+
+		ip, retryAfter, retryable, err := requestIP(&client, endpoint)
+		if err == nil {
+			metrics.recordRetryAttempts(attempt)
+			return ip, nil
+		}
+
+		lastErr = err
+		if !retryable {
+			metrics.recordRetryAttempts(attempt)
+			return "", err
+		}
+		if retryAfter > 0 {
+			time.Sleep(retryAfter)
+		}
+	}
+
+	metrics.recordRetryAttempts(config.MaxRetries)
+	return "", fmt.Errorf("failed to fetch IP after %d attempts: %v", config.MaxRetries, lastErr)
+}
+
+// requestIP performs a single HTTP GET against endpoint and classifies the
+// outcome. retryable indicates whether the caller should attempt again, and
+// retryAfter, when non-zero, is an additional delay requested by the server.
+func requestIP(client *http.Client, endpoint string) (ip string, retryAfter time.Duration, retryable bool, err error) {
+	resp, err := client.Get(endpoint)
+	if err != nil {
+		return "", 0, true, fmt.Errorf("request failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		retryable = isRetryableStatus(resp.StatusCode)
+		if retryable {
+			retryAfter = parseRetryAfter(resp.Header.Get("Retry-After"))
+		}
+		return "", retryAfter, retryable, fmt.Errorf("HTTP status %d", resp.StatusCode)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", 0, true, fmt.Errorf("failed to read response: %v", err)
+	}
+
+	// Try to parse as JSON format (e.g., from ipify)
+	var ipResponse IPifyResponse
+	if err := json.Unmarshal(body, &ipResponse); err == nil && ipResponse.IP != "" {
+		return ipResponse.IP, 0, false, nil
+	}
+
+	// If JSON parsing fails, assume response is plain text
+	return string(body), 0, false, nil
+}
+
+// isRetryableStatus reports whether a non-2xx status code is worth retrying.
+// 5xx, 429 and 408 are transient; other 4xx responses fail fast.
+func isRetryableStatus(status int) bool {
+	if status == http.StatusTooManyRequests || status == http.StatusRequestTimeout {
+		return true
+	}
+	return status >= 500
+}
+
+// parseRetryAfter parses a Retry-After header given in seconds, returning
+// zero if the header is absent or not a delay-seconds value.
+func parseRetryAfter(header string) time.Duration {
+	if header == "" {
+		return 0
+	}
+	seconds, err := strconv.Atoi(header)
+	if err != nil || seconds < 0 {
+		return 0
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+// backoffDelay computes the exponential backoff delay before retry attempt
+// n+1, where n is the number of attempts already made. With jitter enabled
+// it returns a random duration in [0, cap); otherwise it returns cap itself.
+func backoffDelay(config *Config, n int) time.Duration {
+	base := config.RetryBaseDelay
+	maxDelay := config.RetryMaxDelay
+	if base <= 0 {
+		base = 500 * time.Millisecond
+	}
+	if maxDelay <= 0 {
+		maxDelay = 30 * time.Second
+	}
+
+	cap := base << uint(n-1)
+	if cap <= 0 || cap > maxDelay {
+		cap = maxDelay
+	}
+
+	if !config.RetryJitter {
+		return cap
+	}
+	return time.Duration(rand.Int63n(int64(cap)))
+}
+
+// breakerOpen reports whether the circuit breaker is currently open, in
+// which case checkIP should short-circuit without contacting the endpoint.
+func breakerOpen(config *Config) (bool, time.Time) {
+	config.BreakerLock.Lock()
+	defer config.BreakerLock.Unlock()
+
+	if config.BreakerOpenUntil.IsZero() {
+		return false, time.Time{}
+	}
+	if time.Now().Before(config.BreakerOpenUntil) {
+		return true, config.BreakerOpenUntil
+	}
+
+	// Cool-down has elapsed; close the breaker and let the next check through.
+	config.BreakerOpenUntil = time.Time{}
+	config.BreakerFailures = 0
+	logInfo("Circuit breaker closed, resuming checks", kv("event", "breaker_closed"))
+	return false, time.Time{}
+}
+
+// breakerCooldown is how long the circuit breaker stays open once tripped.
+const breakerCooldown = 1 * time.Minute
+
+// recordBreakerFailure registers a failed check cycle, opening the breaker
+// once config.BreakerThreshold consecutive failures have been observed.
+func recordBreakerFailure(config *Config) {
+	if config.BreakerThreshold <= 0 {
+		return
+	}
+
+	config.BreakerLock.Lock()
+	defer config.BreakerLock.Unlock()
+
+	config.BreakerFailures++
+	if config.BreakerFailures >= config.BreakerThreshold && config.BreakerOpenUntil.IsZero() {
+		config.BreakerOpenUntil = time.Now().Add(breakerCooldown)
+		logWarn(fmt.Sprintf("Circuit breaker open after %d consecutive failures, cooling down until %s",
+			config.BreakerFailures, config.BreakerOpenUntil.Format(time.RFC3339)),
+			kv("event", "breaker_open"), kv("failures", config.BreakerFailures), kv("open_until", config.BreakerOpenUntil.Format(time.RFC3339)))
+	}
+}
+
+// recordBreakerSuccess resets the failure count after a successful check.
+func recordBreakerSuccess(config *Config) {
+	config.BreakerLock.Lock()
+	defer config.BreakerLock.Unlock()
+	config.BreakerFailures = 0
+}
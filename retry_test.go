@@ -0,0 +1,150 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestFetchExternalIPFailsFastOnNonRetryableStatus(t *testing.T) {
+	requestCount := 0
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestCount++
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer testServer.Close()
+
+	config := testRetryConfig(5)
+	_, err := fetchExternalIP(config, testServer.URL)
+	if err == nil {
+		t.Fatal("Expected error for 400 response")
+	}
+	if requestCount != 1 {
+		t.Errorf("Expected exactly 1 request for a non-retryable status, got %d", requestCount)
+	}
+}
+
+func TestFetchExternalIPRetriesOnRetryableStatus(t *testing.T) {
+	requestCount := 0
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestCount++
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer testServer.Close()
+
+	config := testRetryConfig(3)
+	_, err := fetchExternalIP(config, testServer.URL)
+	if err == nil {
+		t.Fatal("Expected error after exhausting retries")
+	}
+	if requestCount != 3 {
+		t.Errorf("Expected 3 requests for a 429 response, got %d", requestCount)
+	}
+}
+
+func TestFetchExternalIPHonorsRetryAfter(t *testing.T) {
+	requestCount := 0
+	var timestamps []time.Time
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		timestamps = append(timestamps, time.Now())
+		requestCount++
+		if requestCount < 2 {
+			w.Header().Set("Retry-After", "1")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		w.Write([]byte("1.2.3.4"))
+	}))
+	defer testServer.Close()
+
+	config := testRetryConfig(3)
+	start := time.Now()
+	ip, err := fetchExternalIP(config, testServer.URL)
+	if err != nil {
+		t.Fatalf("Expected success on second attempt, got %v", err)
+	}
+	if ip != "1.2.3.4" {
+		t.Errorf("Expected IP 1.2.3.4, got %s", ip)
+	}
+	if time.Since(start) < time.Second {
+		t.Errorf("Expected fetch to honor the 1s Retry-After delay")
+	}
+}
+
+func TestBackoffDelayRespectsCapAndJitter(t *testing.T) {
+	config := &Config{
+		RetryBaseDelay: 100 * time.Millisecond,
+		RetryMaxDelay:  300 * time.Millisecond,
+		RetryJitter:    false,
+	}
+
+	if got := backoffDelay(config, 1); got != 100*time.Millisecond {
+		t.Errorf("Expected 100ms for attempt 1, got %v", got)
+	}
+	if got := backoffDelay(config, 2); got != 200*time.Millisecond {
+		t.Errorf("Expected 200ms for attempt 2, got %v", got)
+	}
+	if got := backoffDelay(config, 5); got != 300*time.Millisecond {
+		t.Errorf("Expected delay capped at 300ms, got %v", got)
+	}
+
+	config.RetryJitter = true
+	for i := 0; i < 20; i++ {
+		got := backoffDelay(config, 5)
+		if got < 0 || got > 300*time.Millisecond {
+			t.Fatalf("Jittered delay %v out of bounds [0, 300ms]", got)
+		}
+	}
+}
+
+func TestCircuitBreakerOpensAndCoolsDown(t *testing.T) {
+	config := &Config{BreakerThreshold: 2}
+
+	recordBreakerFailure(config)
+	if open, _ := breakerOpen(config); open {
+		t.Fatal("Breaker should stay closed before reaching the threshold")
+	}
+
+	recordBreakerFailure(config)
+	open, until := breakerOpen(config)
+	if !open {
+		t.Fatal("Breaker should open once the threshold is reached")
+	}
+	if !until.After(time.Now()) {
+		t.Errorf("Expected cool-down to be in the future, got %v", until)
+	}
+
+	recordBreakerSuccess(config)
+	if config.BreakerFailures != 0 {
+		t.Errorf("Expected failure count reset after success, got %d", config.BreakerFailures)
+	}
+}
+
+func TestCheckIPSkipsWhenBreakerOpen(t *testing.T) {
+	requestCount := 0
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestCount++
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer testServer.Close()
+
+	config := &Config{
+		Providers:        providersFromURLs([]string{testServer.URL}),
+		Quorum:           1,
+		MaxRetries:       1,
+		RetryBaseDelay:   time.Millisecond,
+		RetryMaxDelay:    time.Millisecond,
+		BreakerThreshold: 1,
+	}
+
+	checkIP(config)
+	if requestCount != 1 {
+		t.Fatalf("Expected 1 request before the breaker opens, got %d", requestCount)
+	}
+
+	checkIP(config)
+	if requestCount != 1 {
+		t.Errorf("Expected checkIP to skip the request while the breaker is open, got %d requests", requestCount)
+	}
+}